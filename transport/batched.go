@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WAMP batched subprotocols frame multiple messages into a single
+// websocket frame, to reduce the number of frames exchanged for bursts of
+// small messages:
+//
+//   - wamp.2.json.batched messages are UTF-8 JSON documents, each one
+//     terminated by a U+001E (record separator) character.
+//   - wamp.2.msgpack.batched messages are each prefixed by a 4-byte,
+//     big-endian length.
+const (
+	jsonBatchedWebsocketProtocol    = "wamp.2.json.batched"
+	msgpackBatchedWebsocketProtocol = "wamp.2.msgpack.batched"
+
+	jsonBatchSeparator = 0x1e
+
+	// defaultBatchWindow is used when WebsocketConfig.Batched is set but
+	// BatchWindow is zero, so that low-rate sessions are not penalized by
+	// waiting forever for a batch to fill.
+	defaultBatchWindow = time.Millisecond
+)
+
+// BatchConfig configures coalescing of outgoing messages into
+// batched-subprotocol frames for a peer created directly through
+// NewWebsocketPeer.  ConnectWebsocketPeerContext derives this automatically
+// from WebsocketConfig when WebsocketConfig.Batched is set.
+type BatchConfig struct {
+	// BatchWindow bounds how long outgoing messages are held open for more
+	// messages to coalesce into the same frame.  Zero uses
+	// defaultBatchWindow.
+	BatchWindow time.Duration
+
+	// MaxBatchBytes is the total serialized message size at which a batch
+	// is flushed immediately instead of waiting out BatchWindow.  The
+	// message that reaches it is included, so a frame can exceed it.
+	// Zero means no size-based flush.
+	MaxBatchBytes int
+}
+
+// encodeBatch concatenates msgs, already individually serialized, into a
+// single websocket frame payload using the framing appropriate for
+// payloadType.
+func encodeBatch(msgs [][]byte, payloadType int) []byte {
+	if payloadType == websocket.TextMessage {
+		return encodeJSONBatch(msgs)
+	}
+	return encodeMsgpackBatch(msgs)
+}
+
+func encodeJSONBatch(msgs [][]byte) []byte {
+	size := 0
+	for _, m := range msgs {
+		size += len(m) + 1
+	}
+	out := make([]byte, 0, size)
+	for _, m := range msgs {
+		out = append(out, m...)
+		out = append(out, jsonBatchSeparator)
+	}
+	return out
+}
+
+func encodeMsgpackBatch(msgs [][]byte) []byte {
+	size := 0
+	for _, m := range msgs {
+		size += 4 + len(m)
+	}
+	out := make([]byte, 0, size)
+	var lenBuf [4]byte
+	for _, m := range msgs {
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(m)))
+		out = append(out, lenBuf[:]...)
+		out = append(out, m...)
+	}
+	return out
+}
+
+// decodeBatch splits a websocket frame payload, received over a batched
+// subprotocol, back into the individual serialized messages it contains.
+func decodeBatch(b []byte, payloadType int) ([][]byte, error) {
+	if payloadType == websocket.TextMessage {
+		return decodeJSONBatch(b)
+	}
+	return decodeMsgpackBatch(b)
+}
+
+func decodeJSONBatch(b []byte) ([][]byte, error) {
+	var msgs [][]byte
+	start := 0
+	for i, c := range b {
+		if c == jsonBatchSeparator {
+			msgs = append(msgs, b[start:i])
+			start = i + 1
+		}
+	}
+	if start != len(b) {
+		return nil, errors.New("batched frame missing trailing record separator")
+	}
+	return msgs, nil
+}
+
+func decodeMsgpackBatch(b []byte) ([][]byte, error) {
+	var msgs [][]byte
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, errors.New("truncated batched msgpack length prefix")
+		}
+		n := binary.BigEndian.Uint32(b[:4])
+		b = b[4:]
+		if uint32(len(b)) < n {
+			return nil, errors.New("truncated batched msgpack message")
+		}
+		msgs = append(msgs, b[:n])
+		b = b[n:]
+	}
+	return msgs, nil
+}
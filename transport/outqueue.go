@@ -0,0 +1,363 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/wamp"
+)
+
+// QueueStats is a snapshot of an OutboundQueue's instrumentation counters.
+type QueueStats struct {
+	// Enqueued is the total number of messages successfully queued.
+	Enqueued uint64
+	// Dropped is the total number of messages discarded by the queue's
+	// drop policy: incoming messages under DropNewest, and already queued
+	// messages evicted to make room under DropOldest.
+	Dropped uint64
+	// HighWaterMark is the largest depth the queue has reached.
+	HighWaterMark int
+	// Depth is the current number of messages waiting to be sent.
+	Depth int
+}
+
+// OutboundQueue buffers messages waiting to be written to a websocket
+// connection, between the goroutine calling Peer.Send/TrySend and the
+// sendHandler goroutine that drains Out().  Implementations differ in what
+// happens when the queue is full: block the caller, drop the oldest queued
+// message, drop the incoming message, or grow without bound.
+//
+// An OutboundQueue is used by a single websocketPeer and is not safe to
+// share between peers.
+type OutboundQueue interface {
+	// Push enqueues msg, applying the queue's policy if the queue is at
+	// capacity.  A nil msg is used internally as a shutdown sentinel and is
+	// always enqueued.
+	Push(msg wamp.Message)
+
+	// TryPush enqueues msg, waiting up to timeout if the queue is at
+	// capacity, and returns an error if msg could not be enqueued in time.
+	// Drop-oldest, drop-newest, and growable queues never block on a non-nil
+	// msg, so they always return nil for one.
+	TryPush(msg wamp.Message, timeout time.Duration) error
+
+	// PushContext enqueues msg, aborting with ctx.Err() if ctx is done
+	// before msg can be enqueued.  Drop-oldest, drop-newest, and growable
+	// queues never block on a non-nil msg, so they always return nil for
+	// one.
+	PushContext(ctx context.Context, msg wamp.Message) error
+
+	// Drain removes and returns, in order, every real message currently
+	// queued, without blocking.  It is used to recover messages that were
+	// handed to Push but never written to the socket, e.g. when a peer's
+	// connection dies and the caller wants to re-queue them elsewhere
+	// instead of losing them.  The nil shutdown sentinel is never returned.
+	Drain() []wamp.Message
+
+	// Clone returns a new, empty OutboundQueue with the same configuration
+	// (capacity, drop policy, etc.) as this one.  An OutboundQueue is not
+	// safe to share between peers, so callers that dial a replacement peer
+	// for an existing one, such as reconnectingWebsocketPeer, use Clone
+	// instead of reusing the original instance.
+	Clone() OutboundQueue
+
+	// Out returns the channel that sendHandler drains, in order, to obtain
+	// the next message to write to the websocket.
+	Out() <-chan wamp.Message
+
+	// Stats returns a snapshot of the queue's counters.
+	Stats() QueueStats
+}
+
+// StatsPeer is implemented by peers that expose OutboundQueue
+// instrumentation.  Since wamp.Peer does not declare Stats, callers type
+// assert for it:
+//
+//	if sp, ok := peer.(transport.StatsPeer); ok {
+//		stats := sp.Stats()
+//	}
+type StatsPeer interface {
+	Stats() QueueStats
+}
+
+// DropPolicy selects what a bounded OutboundQueue does when Push is called
+// while the queue is full.
+type DropPolicy int
+
+const (
+	// DropNone blocks Push until space is available.  This is the
+	// historical behavior of the fixed-size wr channel.
+	DropNone DropPolicy = iota
+	// DropOldest discards the oldest queued message to make room for the
+	// new one.
+	DropOldest
+	// DropNewest discards the incoming message, leaving the queue
+	// unchanged.
+	DropNewest
+)
+
+// NewBoundedQueue creates an OutboundQueue with the given capacity and drop
+// policy.  A capacity <= 0 defaults to outQueueSize.
+func NewBoundedQueue(capacity int, policy DropPolicy) OutboundQueue {
+	if capacity <= 0 {
+		capacity = outQueueSize
+	}
+	return &boundedQueue{
+		out:    make(chan wamp.Message, capacity),
+		policy: policy,
+	}
+}
+
+// NewGrowableQueue creates an unbounded OutboundQueue that never drops or
+// blocks a producer; the queue grows to hold however many messages are
+// waiting to be sent.
+func NewGrowableQueue() OutboundQueue {
+	q := &growableQueue{
+		out:    make(chan wamp.Message),
+		notify: make(chan struct{}, 1),
+	}
+	go q.pump()
+	return q
+}
+
+// boundedQueue implements OutboundQueue with a fixed-capacity channel and a
+// DropPolicy applied when that channel is full.
+type boundedQueue struct {
+	out    chan wamp.Message
+	policy DropPolicy
+
+	mu    sync.Mutex
+	stats QueueStats
+}
+
+func (q *boundedQueue) Push(msg wamp.Message) {
+	if msg == nil {
+		// Shutdown sentinel: always deliver, blocking if necessary, so
+		// sendHandler is guaranteed to see it.
+		q.out <- nil
+		return
+	}
+
+	switch q.policy {
+	case DropOldest:
+		for {
+			select {
+			case q.out <- msg:
+				q.recordPush()
+				return
+			default:
+			}
+			select {
+			case <-q.out:
+				q.recordDrop()
+			default:
+			}
+		}
+	case DropNewest:
+		select {
+		case q.out <- msg:
+			q.recordPush()
+		default:
+			q.recordDrop()
+		}
+	default: // DropNone
+		q.out <- msg
+		q.recordPush()
+	}
+}
+
+// TryPush enqueues msg, honoring the queue's DropPolicy.  For DropOldest and
+// DropNewest this behaves exactly like Push, since neither policy ever
+// blocks on a non-nil msg.  For DropNone it mirrors the prior TrySend behavior: attempt a
+// non-blocking send first, then wait up to timeout before giving up.
+func (q *boundedQueue) TryPush(msg wamp.Message, timeout time.Duration) error {
+	if q.policy != DropNone {
+		q.Push(msg)
+		return nil
+	}
+
+	select {
+	case q.out <- msg:
+		q.recordPush()
+		return nil
+	default:
+	}
+
+	select {
+	case q.out <- msg:
+		q.recordPush()
+		return nil
+	case <-time.After(timeout):
+		return errors.New("blocked")
+	}
+}
+
+// PushContext enqueues msg, honoring the queue's DropPolicy.  For DropOldest
+// and DropNewest this behaves exactly like Push for a non-nil msg.  For
+// DropNone, and for the nil shutdown sentinel under any policy, it blocks
+// until there is room or ctx is done, whichever comes first.  Like Push, it
+// does not count the sentinel in the queue's stats.
+func (q *boundedQueue) PushContext(ctx context.Context, msg wamp.Message) error {
+	if q.policy != DropNone && msg != nil {
+		q.Push(msg)
+		return nil
+	}
+
+	select {
+	case q.out <- msg:
+		if msg != nil {
+			q.recordPush()
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain removes and returns, in order, every message currently sitting in
+// the channel, without blocking.  The nil shutdown sentinel, if present, is
+// discarded rather than returned.
+func (q *boundedQueue) Drain() []wamp.Message {
+	var msgs []wamp.Message
+	for {
+		select {
+		case msg := <-q.out:
+			if msg != nil {
+				msgs = append(msgs, msg)
+			}
+		default:
+			return msgs
+		}
+	}
+}
+
+// Clone returns a new, empty boundedQueue with the same capacity and drop
+// policy as q.
+func (q *boundedQueue) Clone() OutboundQueue {
+	return NewBoundedQueue(cap(q.out), q.policy)
+}
+
+func (q *boundedQueue) Out() <-chan wamp.Message { return q.out }
+
+func (q *boundedQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Depth = len(q.out)
+	return stats
+}
+
+func (q *boundedQueue) recordPush() {
+	q.mu.Lock()
+	q.stats.Enqueued++
+	if depth := len(q.out); depth > q.stats.HighWaterMark {
+		q.stats.HighWaterMark = depth
+	}
+	q.mu.Unlock()
+}
+
+func (q *boundedQueue) recordDrop() {
+	q.mu.Lock()
+	q.stats.Dropped++
+	q.mu.Unlock()
+}
+
+// growableQueue implements OutboundQueue with an unbounded slice-backed
+// buffer, so Push never blocks and never drops.
+type growableQueue struct {
+	out    chan wamp.Message
+	notify chan struct{}
+
+	mu    sync.Mutex
+	buf   []wamp.Message
+	stats QueueStats
+}
+
+func (q *growableQueue) Push(msg wamp.Message) {
+	q.mu.Lock()
+	q.buf = append(q.buf, msg)
+	if msg != nil {
+		// Shutdown sentinel: enqueued like any other message so pump sees
+		// it in order, but not counted, matching boundedQueue.Push.
+		q.stats.Enqueued++
+		if depth := len(q.buf); depth > q.stats.HighWaterMark {
+			q.stats.HighWaterMark = depth
+		}
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// TryPush enqueues msg.  The growable queue never blocks, so this always
+// succeeds.
+func (q *growableQueue) TryPush(msg wamp.Message, timeout time.Duration) error {
+	q.Push(msg)
+	return nil
+}
+
+// PushContext enqueues msg.  The growable queue never blocks, so this
+// always succeeds and ctx is ignored.
+func (q *growableQueue) PushContext(ctx context.Context, msg wamp.Message) error {
+	q.Push(msg)
+	return nil
+}
+
+// Drain removes and returns, in order, every message currently buffered,
+// without blocking.  The nil shutdown sentinel, if present, is discarded
+// rather than returned.  It does not recover a message that pump has
+// already handed off to Out() but that sendHandler has not yet read.
+func (q *growableQueue) Drain() []wamp.Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var msgs []wamp.Message
+	for _, msg := range q.buf {
+		if msg != nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	q.buf = nil
+	return msgs
+}
+
+// Clone returns a new, empty growableQueue.
+func (q *growableQueue) Clone() OutboundQueue {
+	return NewGrowableQueue()
+}
+
+func (q *growableQueue) Out() <-chan wamp.Message { return q.out }
+
+func (q *growableQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Depth = len(q.buf)
+	return stats
+}
+
+// pump moves messages from the growable buffer to the unbuffered out
+// channel, allowing the queue to grow while sendHandler is busy writing to
+// a slow websocket.
+func (q *growableQueue) pump() {
+	for {
+		q.mu.Lock()
+		for len(q.buf) == 0 {
+			q.mu.Unlock()
+			<-q.notify
+			q.mu.Lock()
+		}
+		msg := q.buf[0]
+		q.buf = q.buf[1:]
+		q.mu.Unlock()
+
+		q.out <- msg
+		if msg == nil {
+			return
+		}
+	}
+}
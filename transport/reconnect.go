@@ -0,0 +1,446 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gammazero/nexus/stdlog"
+	"github.com/gammazero/nexus/transport/serialize"
+	"github.com/gammazero/nexus/wamp"
+)
+
+const (
+	// defaultMaxReconnectAttempts is the number of consecutive failed dial
+	// attempts allowed, by default, before the peer gives up and closes.
+	defaultMaxReconnectAttempts = 10
+
+	// reconnectBaseDelay and reconnectMaxDelay bound the exponential
+	// backoff applied between dial attempts.
+	reconnectBaseDelay = 2 * time.Second
+	reconnectMaxDelay  = 17 * time.Minute
+)
+
+// OnReconnectFunc is called after a reconnecting websocket peer has
+// established a new websocket session with the router, and before any
+// backlogged messages are replayed.  Since WAMP assigns a new session ID on
+// every reconnect, this gives the caller a chance to send a fresh HELLO and
+// re-issue SUBSCRIBE/REGISTER requests against the new session before
+// traffic resumes.
+type OnReconnectFunc func(wamp.Peer) error
+
+// ReconnectConfig configures the behavior of a peer created by
+// NewReconnectingWebsocketPeer.
+type ReconnectConfig struct {
+	// MaxReconnectAttempts is the number of consecutive failed dial
+	// attempts allowed before the peer gives up reconnecting and closes
+	// itself.  A value <= 0 defaults to defaultMaxReconnectAttempts.
+	MaxReconnectAttempts int
+
+	// OnReconnect, if set, is called after each successful reconnect and
+	// before any backlogged messages are replayed.
+	OnReconnect OnReconnectFunc
+}
+
+// reconnectingWebsocketPeer wraps a websocketPeer, transparently re-dialing
+// the router whenever the underlying transport is lost.  Messages sent
+// while disconnected are queued, in order, in an unbounded local backlog
+// and replayed once a new session has been established; the backlog never
+// blocks a producer, so it cannot wedge when messages recovered from a
+// dead connection are requeued ahead of it.
+type reconnectingWebsocketPeer struct {
+	routerURL     string
+	serialization serialize.Serialization
+	tlsConfig     *tls.Config
+	dial          DialFunc
+	log           stdlog.StdLog
+	wsCfg         *WebsocketConfig
+	maxAttempts   int
+	onReconnect   OnReconnectFunc
+
+	rd        chan wamp.Message
+	backlog   OutboundQueue
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu          sync.Mutex
+	peer        wamp.Peer
+	connected   bool
+	reconnectCh chan struct{} // closed and replaced whenever connected/reconnecting state changes
+}
+
+// NewReconnectingWebsocketPeer creates a websocket peer that automatically
+// re-dials routerURL, using exponential backoff with jitter, whenever the
+// connection is lost.  It otherwise behaves like the peer returned by
+// ConnectWebsocketPeerContext.
+//
+// If rcCfg is nil, default reconnect settings are used.
+func NewReconnectingWebsocketPeer(
+	ctx context.Context,
+	routerURL string,
+	serialization serialize.Serialization,
+	tlsConfig *tls.Config,
+	dial DialFunc,
+	logger stdlog.StdLog,
+	wsCfg *WebsocketConfig,
+	rcCfg *ReconnectConfig) (wamp.Peer, error) {
+
+	w := &reconnectingWebsocketPeer{
+		routerURL:     routerURL,
+		serialization: serialization,
+		tlsConfig:     tlsConfig,
+		dial:          dial,
+		log:           logger,
+		wsCfg:         wsCfg,
+		maxAttempts:   defaultMaxReconnectAttempts,
+
+		rd:          make(chan wamp.Message),
+		backlog:     NewGrowableQueue(),
+		closed:      make(chan struct{}),
+		reconnectCh: make(chan struct{}),
+	}
+	if rcCfg != nil {
+		if rcCfg.MaxReconnectAttempts > 0 {
+			w.maxAttempts = rcCfg.MaxReconnectAttempts
+		}
+		w.onReconnect = rcCfg.OnReconnect
+	}
+
+	peer, err := ConnectWebsocketPeerContext(ctx, routerURL, serialization, tlsConfig, dial, logger, w.dialConfig())
+	if err != nil {
+		return nil, err
+	}
+	w.peer = peer
+	w.connected = true
+
+	go w.recvLoop()
+	go w.sendLoop()
+
+	return w, nil
+}
+
+func (w *reconnectingWebsocketPeer) Recv() <-chan wamp.Message { return w.rd }
+
+// TrySend enqueues msg onto the backlog.  The backlog is unbounded, so this
+// never blocks and behaves exactly like Send.
+func (w *reconnectingWebsocketPeer) TrySend(msg wamp.Message) error {
+	return w.Send(msg)
+}
+
+// Send enqueues msg onto the backlog, regardless of whether a session is
+// currently connected; it is actually written once sendLoop has a live
+// underlying peer to hand it to.  The backlog is unbounded, so this never
+// blocks on enqueue itself.
+func (w *reconnectingWebsocketPeer) Send(msg wamp.Message) error {
+	select {
+	case <-w.closed:
+		return errors.New("peer closed")
+	default:
+	}
+	w.backlog.Push(msg)
+	return nil
+}
+
+// SendContext enqueues msg onto the backlog, aborting with ctx.Err() if ctx
+// is done first.  Like Send, the message is queued regardless of whether a
+// session is currently connected, and the backlog is unbounded so this
+// never blocks on enqueue itself.
+func (w *reconnectingWebsocketPeer) SendContext(ctx context.Context, msg wamp.Message) error {
+	select {
+	case <-w.closed:
+		return errors.New("peer closed")
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	w.backlog.Push(msg)
+	return nil
+}
+
+// RecvContext waits for the next message from the peer, aborting with
+// ctx.Err() if ctx is done first.  It returns an error once the wrapper has
+// been closed.
+func (w *reconnectingWebsocketPeer) RecvContext(ctx context.Context) (wamp.Message, error) {
+	select {
+	case msg, open := <-w.rd:
+		if !open {
+			return nil, errors.New("peer closed")
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the currently connected underlying peer's
+// outbound queue counters.  It returns the zero value while reconnecting,
+// or if the underlying peer does not expose stats.
+func (w *reconnectingWebsocketPeer) Stats() QueueStats {
+	peer, connected, _ := w.currentPeer()
+	if !connected {
+		return QueueStats{}
+	}
+	if sp, ok := peer.(StatsPeer); ok {
+		return sp.Stats()
+	}
+	return QueueStats{}
+}
+
+// Close shuts down the reconnecting peer and the underlying websocket
+// connection, waiting up to ctrlTimeout for it to close gracefully.  No
+// further reconnect attempts are made.  It is retained, alongside
+// CloseWithTimeout, for compatibility with existing callers.
+func (w *reconnectingWebsocketPeer) Close() {
+	w.CloseWithTimeout(ctrlTimeout)
+}
+
+// CloseWithTimeout shuts down the reconnecting peer, waiting up to d for
+// the current underlying connection to drain its outbound queue and
+// complete the goodbye handshake, if it supports CloseTimeoutPeer.  No
+// further reconnect attempts are made.
+func (w *reconnectingWebsocketPeer) CloseWithTimeout(d time.Duration) error {
+	w.closeOnce.Do(func() { close(w.closed) })
+	w.mu.Lock()
+	peer := w.peer
+	w.mu.Unlock()
+	if peer == nil {
+		return nil
+	}
+	if ctp, ok := peer.(CloseTimeoutPeer); ok {
+		return ctp.CloseWithTimeout(d)
+	}
+	peer.Close()
+	return nil
+}
+
+// Connected reports whether the peer currently has a live websocket session
+// with the router.
+func (w *reconnectingWebsocketPeer) Connected() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.connected
+}
+
+// Reconnecting reports whether the peer is currently between sessions,
+// attempting to re-dial the router.
+func (w *reconnectingWebsocketPeer) Reconnecting() bool {
+	return !w.Connected()
+}
+
+// currentPeer returns the active underlying peer, along with the channel
+// that is closed the next time the connected/reconnecting state changes.
+func (w *reconnectingWebsocketPeer) currentPeer() (wamp.Peer, bool, chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.peer, w.connected, w.reconnectCh
+}
+
+// setState installs a new underlying peer and connected state, waking up
+// anything waiting on the previous reconnectCh.
+func (w *reconnectingWebsocketPeer) setState(peer wamp.Peer, connected bool) {
+	w.mu.Lock()
+	w.peer = peer
+	w.connected = connected
+	ch := w.reconnectCh
+	w.reconnectCh = make(chan struct{})
+	w.mu.Unlock()
+	close(ch)
+}
+
+// recvLoop forwards messages from the active underlying peer to w.rd, and
+// triggers a reconnect whenever the underlying peer's Recv channel closes.
+func (w *reconnectingWebsocketPeer) recvLoop() {
+	defer close(w.rd)
+	for {
+		peer, connected, stateCh := w.currentPeer()
+		if !connected {
+			select {
+			case <-stateCh:
+				continue
+			case <-w.closed:
+				return
+			}
+		}
+
+		select {
+		case msg, open := <-peer.Recv():
+			if !open {
+				if !w.reconnect(peer) {
+					return
+				}
+				continue
+			}
+			select {
+			case w.rd <- msg:
+			case <-w.closed:
+				return
+			}
+		case <-w.closed:
+			return
+		}
+	}
+}
+
+// sendLoop drains the backlog, in order, sending each message to whichever
+// underlying peer is current.  While disconnected it blocks until a new
+// session is available before sending the next message.
+func (w *reconnectingWebsocketPeer) sendLoop() {
+	for {
+		var msg wamp.Message
+		select {
+		case msg = <-w.backlog.Out():
+		case <-w.closed:
+			return
+		}
+
+		for {
+			peer, connected, stateCh := w.currentPeer()
+			if !connected {
+				select {
+				case <-stateCh:
+					continue
+				case <-w.closed:
+					return
+				}
+			}
+			if err := peer.Send(msg); err != nil {
+				// The underlying peer will be replaced by recvLoop once it
+				// notices the transport is gone; wait for that to happen
+				// and then resend this message.
+				select {
+				case <-stateCh:
+					continue
+				case <-w.closed:
+					return
+				}
+			}
+			break
+		}
+	}
+}
+
+// reconnect re-dials the router with exponential backoff and jitter,
+// giving up after MaxReconnectAttempts failures.  It returns false if the
+// peer was closed or reconnecting was abandoned.
+func (w *reconnectingWebsocketPeer) reconnect(lost wamp.Peer) bool {
+	w.mu.Lock()
+	current := w.peer
+	w.mu.Unlock()
+	if current == lost {
+		w.setState(nil, false)
+	}
+	w.requeueUnsent(lost)
+
+	for attempt := 0; attempt < w.maxAttempts; attempt++ {
+		select {
+		case <-w.closed:
+			return false
+		default:
+		}
+
+		if attempt > 0 {
+			time.Sleep(reconnectBackoff(attempt))
+		}
+
+		peer, err := ConnectWebsocketPeerContext(context.Background(), w.routerURL, w.serialization, w.tlsConfig, w.dial, w.log, w.dialConfig())
+		if err != nil {
+			w.log.Print(fmt.Sprintf("reconnect attempt %d failed: %v", attempt+1, err))
+			continue
+		}
+
+		// Close() may have run while the dial above was in flight; without
+		// this check the freshly dialed peer, and its recvHandler/
+		// sendHandler goroutines, would be installed and never closed.
+		select {
+		case <-w.closed:
+			peer.Close()
+			return false
+		default:
+		}
+
+		if w.onReconnect != nil {
+			if err := w.onReconnect(peer); err != nil {
+				w.log.Print(fmt.Sprintf("OnReconnect failed: %v", err))
+				peer.Close()
+				continue
+			}
+		}
+
+		select {
+		case <-w.closed:
+			peer.Close()
+			return false
+		default:
+		}
+
+		w.setState(peer, true)
+		return true
+	}
+
+	w.log.Print(fmt.Sprintf("giving up after %d reconnect attempts", w.maxAttempts))
+	w.closeOnce.Do(func() { close(w.closed) })
+	return false
+}
+
+// requeueUnsent recovers messages that were handed to lost's outbound queue
+// but never written to the socket before its connection died, and puts them
+// back at the front of the backlog, ahead of anything still buffered
+// there, so they are resent, in order, once a new session is established.
+// A message that has already left the backlog's buffer, i.e. one held by
+// sendLoop or handed off by the backlog's pump, is not moved and is sent
+// before them.
+func (w *reconnectingWebsocketPeer) requeueUnsent(lost wamp.Peer) {
+	wp, ok := lost.(*websocketPeer)
+	if !ok {
+		return
+	}
+	pending := wp.queue.Drain()
+	if len(pending) == 0 {
+		return
+	}
+	w.log.Print(fmt.Sprintf("requeuing %d unsent message(s) after disconnect", len(pending)))
+
+	// The backlog is unbounded, so neither of these Push calls can block,
+	// regardless of how much pending or waiting already holds.
+	waiting := w.backlog.Drain()
+	for _, msg := range pending {
+		w.backlog.Push(msg)
+	}
+	for _, msg := range waiting {
+		w.backlog.Push(msg)
+	}
+}
+
+// dialConfig returns the WebsocketConfig to use for the peer's next dial.
+// If the caller supplied a custom OutboundQueue, a fresh instance is
+// cloned for this dial rather than reusing the one threaded through wsCfg:
+// an OutboundQueue is documented as belonging to a single websocketPeer,
+// and sharing one between a dying peer and its replacement would let
+// requeueUnsent drain messages that actually belong to the new connection.
+func (w *reconnectingWebsocketPeer) dialConfig() *WebsocketConfig {
+	if w.wsCfg == nil || w.wsCfg.Queue == nil {
+		return w.wsCfg
+	}
+	cfg := *w.wsCfg
+	cfg.Queue = w.wsCfg.Queue.Clone()
+	return &cfg
+}
+
+// reconnectBackoff returns the delay before the given reconnect attempt
+// (0-based; reconnect never sleeps before attempt 0), which is
+// reconnectBaseDelay << attempt capped at reconnectMaxDelay, so the first
+// delay is twice reconnectBaseDelay, with up to 50% jitter added to avoid
+// thundering-herd reconnects.  With defaultMaxReconnectAttempts,
+// the last attempt's delay reaches reconnectMaxDelay.
+func reconnectBackoff(attempt int) time.Duration {
+	delay := reconnectBaseDelay << uint(attempt)
+	if delay <= 0 || delay > reconnectMaxDelay {
+		delay = reconnectMaxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 2))
+	return delay + jitter
+}
@@ -22,6 +22,20 @@ type WebsocketConfig struct {
 	// Request per message write compression, if allowed by server.
 	EnableCompression bool `json:"enable_compression"`
 
+	// CompressionThreshold is the minimum serialized message size, in
+	// bytes, for which write compression is enabled.  Messages smaller than
+	// this, such as HELLO, PING, and small YIELD frames, are sent
+	// uncompressed to avoid paying the deflate CPU cost for little benefit.
+	// Only meaningful when EnableCompression is true.  Zero compresses
+	// every message.
+	CompressionThreshold int `json:"compression_threshold"`
+
+	// CompressionLevel sets the flate compression level used for write
+	// compression, as accepted by websocket.Conn.SetCompressionLevel
+	// (flate.BestSpeed through flate.BestCompression).  Zero uses gorilla's
+	// default level.
+	CompressionLevel int `json:"compression_level"`
+
 	// If provided when configuring websocket client, cookies from server are
 	// put in here.  This allows cookies to be stored and then sent back to the
 	// server in subsequent websocket connections.  Cookies may be used to
@@ -32,6 +46,52 @@ type WebsocketConfig struct {
 	// If not defined, the proxy defined by the environment is used if defined.
 	ProxyURL string
 
+	// ReadWait is the time allowed to read a message from the peer.  If no
+	// message, and no pong answering a PingPeriod heartbeat ping, is read
+	// within this interval the websocket is closed.  Pings received from the
+	// peer do not extend the deadline.  Zero disables the read deadline.
+	ReadWait time.Duration `json:"read_wait"`
+
+	// WriteWait is the time allowed to write a message to the peer.  This
+	// bounds how long a write can block on a slow or dead connection.  Zero
+	// disables the write deadline.
+	WriteWait time.Duration `json:"write_wait"`
+
+	// PingPeriod is the interval at which websocket "ping" heartbeats are
+	// sent to the peer.  This is passed to NewWebsocketPeer as
+	// WebsocketPeerConfig.KeepAlive.  Zero disables the heartbeat.
+	PingPeriod time.Duration `json:"ping_period"`
+
+	// Queue is the OutboundQueue used to buffer messages waiting to be
+	// written to the websocket.  If nil, a bounded-block queue of size
+	// outQueueSize is used, matching the behavior of a plain fixed-size
+	// channel.
+	Queue OutboundQueue
+
+	// Batched negotiates the batched form of the JSON or msgpack
+	// subprotocol (wamp.2.json.batched, wamp.2.msgpack.batched), coalescing
+	// multiple outgoing messages into a single websocket frame.  Has no
+	// effect when CBOR serialization is used or when PingPeriod is non-zero.
+	//
+	// This is a deliberate scope reduction from dedicated
+	// serialize.Serialization values (e.g. JSON_BATCHED, MSGPACK_BATCHED):
+	// a bool alongside the existing serialization keeps the change confined
+	// to this package instead of also touching serialize and router-side
+	// handshake code for the same negotiated outcome.
+	Batched bool `json:"batched"`
+
+	// BatchWindow bounds how long outgoing messages are held open for more
+	// messages to coalesce into the same frame.  Only used when Batched is
+	// true.  Zero uses defaultBatchWindow, so low-rate sessions are not
+	// penalized waiting for a batch to fill.
+	BatchWindow time.Duration `json:"batch_window"`
+
+	// MaxBatchBytes is the total serialized message size at which a batch
+	// is flushed immediately instead of waiting out BatchWindow.  The
+	// message that reaches it is included, so a frame can exceed it.
+	// Zero means no size-based flush, only BatchWindow.
+	MaxBatchBytes int `json:"max_batch_bytes"`
+
 	// Deprecated server config options.
 	// See: https://godoc.org/github.com/gammazero/nexus/router#WebsocketServer
 	EnableTrackingCookie bool `json:"enable_tracking_cookie"`
@@ -48,12 +108,35 @@ type websocketPeer struct {
 	// Used to signal the websocket is closed explicitly.
 	closed chan struct{}
 
-	// Channels communicate with router.
+	// rd is read from by the router to receive incoming messages.
 	rd chan wamp.Message
-	wr chan wamp.Message
+
+	// recvDone is closed when recvHandler exits, whether because the close
+	// handshake completed or because of a read error.  Unlike rd, it is not
+	// used to carry messages, so CloseWithTimeout can wait on it without
+	// racing the router's consumption of rd.
+	recvDone chan struct{}
+
+	// queue buffers outgoing messages between Send/TrySend and sendHandler.
+	queue OutboundQueue
 
 	writerDone chan struct{}
 
+	// readWait and writeWait bound individual read and write operations on
+	// the underlying connection.  Zero means no deadline is applied.
+	readWait  time.Duration
+	writeWait time.Duration
+
+	// compressionThreshold is the minimum serialized message size, in
+	// bytes, for which write compression is enabled.
+	compressionThreshold int
+
+	// batched, batchWindow, and maxBatchBytes configure coalescing of
+	// outgoing messages into batched-subprotocol frames.
+	batched       bool
+	batchWindow   time.Duration
+	maxBatchBytes int
+
 	log stdlog.StdLog
 }
 
@@ -104,19 +187,39 @@ func ConnectWebsocketPeerContext(
 		err error
 	)
 
+	batched := wsCfg != nil && wsCfg.Batched
+	if batched && wsCfg.PingPeriod != 0 {
+		// NewWebsocketPeer gives keepAlive precedence over batching and
+		// will not enable batched framing when both are set, so
+		// negotiating a batched subprotocol here would leave the server
+		// expecting batched frames that are never sent.
+		logger.Println("Warning: batched subprotocol is not supported with PingPeriod (keepAlive), ignoring Batched config")
+		batched = false
+	}
+
 	switch serialization {
 	case serialize.JSON:
 		protocol = jsonWebsocketProtocol
 		payloadType = websocket.TextMessage
 		serializer = &serialize.JSONSerializer{}
+		if batched {
+			protocol = jsonBatchedWebsocketProtocol
+		}
 	case serialize.MSGPACK:
 		protocol = msgpackWebsocketProtocol
 		payloadType = websocket.BinaryMessage
 		serializer = &serialize.MessagePackSerializer{}
+		if batched {
+			protocol = msgpackBatchedWebsocketProtocol
+		}
 	case serialize.CBOR:
 		protocol = cborWebsocketProtocol
 		payloadType = websocket.BinaryMessage
 		serializer = &serialize.CBORSerializer{}
+		if batched {
+			logger.Println("Warning: batched subprotocol is not defined for CBOR, ignoring Batched config")
+			batched = false
+		}
 	default:
 		return nil, fmt.Errorf("unsupported serialization: %v", serialization)
 	}
@@ -137,7 +240,7 @@ func ConnectWebsocketPeerContext(
 			dialer.Proxy = http.ProxyURL(proxyURL)
 		}
 		dialer.Jar = wsCfg.Jar
-		dialer.EnableCompression = true
+		dialer.EnableCompression = wsCfg.EnableCompression
 	}
 
 	if ctx == nil {
@@ -149,18 +252,78 @@ func ConnectWebsocketPeerContext(
 	if err != nil {
 		return nil, err
 	}
-	return NewWebsocketPeer(conn, serializer, payloadType, logger, 0), nil
+
+	peerCfg := &WebsocketPeerConfig{}
+	if wsCfg != nil {
+		peerCfg.KeepAlive = wsCfg.PingPeriod
+		peerCfg.ReadWait = wsCfg.ReadWait
+		peerCfg.WriteWait = wsCfg.WriteWait
+		peerCfg.Queue = wsCfg.Queue
+		peerCfg.CompressionThreshold = wsCfg.CompressionThreshold
+		if wsCfg.EnableCompression && wsCfg.CompressionLevel != 0 {
+			conn.SetCompressionLevel(wsCfg.CompressionLevel)
+		}
+		if batched {
+			peerCfg.Batch = &BatchConfig{BatchWindow: wsCfg.BatchWindow, MaxBatchBytes: wsCfg.MaxBatchBytes}
+		}
+	}
+	return NewWebsocketPeer(conn, serializer, payloadType, logger, peerCfg), nil
 }
 
 
+// WebsocketPeerConfig configures a websocketPeer created by NewWebsocketPeer.
+// A nil *WebsocketPeerConfig, or a zero-value one, gives every field its
+// documented default.
+type WebsocketPeerConfig struct {
+	// KeepAlive configures a websocket "ping/pong" heartbeat, sending
+	// websocket "pings" every KeepAlive interval.  If a "pong" response is
+	// not received after 2 intervals have elapsed then the websocket is
+	// closed.  Zero disables the heartbeat.
+	KeepAlive time.Duration
+
+	// ReadWait bounds how long a read from the websocket may take before
+	// the connection is considered dead; it is refreshed on every pong, so
+	// a peer that stops responding to pings is detected and closed promptly
+	// instead of relying solely on the pendingPongs counter.  Zero disables
+	// the read deadline.
+	ReadWait time.Duration
+
+	// WriteWait bounds how long an individual write may block.  Zero
+	// disables the write deadline.
+	WriteWait time.Duration
+
+	// Queue buffers messages waiting to be written to the websocket.  If
+	// nil, a bounded-block queue of size outQueueSize is used, matching the
+	// behavior of the previous fixed-size channel.
+	Queue OutboundQueue
+
+	// CompressionThreshold is the minimum serialized message size, in
+	// bytes, for which per-message write compression is enabled; smaller
+	// messages are sent uncompressed.  It has no effect unless the
+	// connection was dialed with compression enabled.
+	CompressionThreshold int
+
+	// Batch, if non-nil, coalesces outgoing messages into frames using the
+	// batched subprotocol framing (wamp.2.json.batched or
+	// wamp.2.msgpack.batched); the connection must have been dialed with
+	// the corresponding batched subprotocol negotiated.  Combining Batch
+	// with a non-zero KeepAlive is not supported; KeepAlive takes
+	// precedence.
+	Batch *BatchConfig
+}
+
 // NewWebsocketPeer creates a websocket peer from an existing websocket
 // connection.  This is used by clients connecting to the WAMP router, and by
-// servers to handle connections from clients.
-//
-// A non-zero keepAlive value configures a websocket "ping/pong" heartbeat,
-// sendings websocket "pings" every keepAlive interval.  If a "pong" response
-// is not received after 2 intervals have elapsed then the websocket is closed.
-func NewWebsocketPeer(conn *websocket.Conn, serializer serialize.Serializer, payloadType int, logger stdlog.StdLog, keepAlive time.Duration) wamp.Peer {
+// servers to handle connections from clients.  A nil cfg is equivalent to a
+// zero-value *WebsocketPeerConfig.
+func NewWebsocketPeer(conn *websocket.Conn, serializer serialize.Serializer, payloadType int, logger stdlog.StdLog, cfg *WebsocketPeerConfig) wamp.Peer {
+	if cfg == nil {
+		cfg = &WebsocketPeerConfig{}
+	}
+	queue := cfg.Queue
+	if queue == nil {
+		queue = NewBoundedQueue(outQueueSize, DropNone)
+	}
 	w := &websocketPeer{
 		conn:        conn,
 		serializer:  serializer,
@@ -171,24 +334,40 @@ func NewWebsocketPeer(conn *websocket.Conn, serializer serialize.Serializer, pay
 		// The router will read from this channel and immediately dispatch the
 		// message to the broker or dealer.  Therefore this channel can be
 		// unbuffered.
-		rd: make(chan wamp.Message),
+		rd:       make(chan wamp.Message),
+		recvDone: make(chan struct{}),
+
+		queue: queue,
 
-		// The channel for messages being written to the websocket should be
-		// large enough to prevent blocking while waiting for a slow websocket
-		// to send messages.  For this reason it may be necessary for these
-		// messages to be put into an outbound queue that can grow.
-		wr: make(chan wamp.Message, outQueueSize),
+		readWait:  cfg.ReadWait,
+		writeWait: cfg.WriteWait,
+
+		compressionThreshold: cfg.CompressionThreshold,
 
 		log: logger,
 	}
+	if cfg.Batch != nil && cfg.KeepAlive == 0 {
+		w.batched = true
+		w.batchWindow = cfg.Batch.BatchWindow
+		if w.batchWindow == 0 {
+			w.batchWindow = defaultBatchWindow
+		}
+		w.maxBatchBytes = cfg.Batch.MaxBatchBytes
+	}
 	// Sending to and receiving from websocket is handled concurrently.
 	go w.recvHandler()
-	if keepAlive != 0 {
-		if keepAlive < time.Second {
+	switch {
+	case cfg.KeepAlive != 0:
+		if cfg.Batch != nil {
+			w.log.Println("Warning: batching is not supported with keepAlive, ignoring BatchConfig")
+		}
+		if cfg.KeepAlive < time.Second {
 			w.log.Println("Warning: very short keepalive (< 1 second)")
 		}
-		go w.sendHandlerKeepAlive(keepAlive)
-	} else {
+		go w.sendHandlerKeepAlive(cfg.KeepAlive)
+	case w.batched:
+		go w.sendHandlerBatched()
+	default:
 		go w.sendHandler()
 	}
 
@@ -198,36 +377,93 @@ func NewWebsocketPeer(conn *websocket.Conn, serializer serialize.Serializer, pay
 func (w *websocketPeer) Recv() <-chan wamp.Message { return w.rd }
 
 func (w *websocketPeer) TrySend(msg wamp.Message) error {
-	select {
-	case w.wr <- msg:
-		return nil
-	default:
-	}
+	return w.queue.TryPush(msg, sendTimeout)
+}
+
+func (w *websocketPeer) Send(msg wamp.Message) error {
+	w.queue.Push(msg)
+	return nil
+}
 
+// SendContext enqueues msg for sending, aborting with ctx.Err() if ctx is
+// done first.  Unlike Send, this lets application code cancel an
+// individual publish/subscribe write instead of racing it against Close.
+func (w *websocketPeer) SendContext(ctx context.Context, msg wamp.Message) error {
+	return w.queue.PushContext(ctx, msg)
+}
+
+// RecvContext waits for the next message from the peer, aborting with
+// ctx.Err() if ctx is done first.  It returns an error if the peer's read
+// channel has been closed.
+func (w *websocketPeer) RecvContext(ctx context.Context) (wamp.Message, error) {
 	select {
-	case w.wr <- msg:
-	case <-time.After(sendTimeout):
-		return errors.New("blocked")
+	case msg, open := <-w.rd:
+		if !open {
+			return nil, errors.New("peer closed")
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return nil
 }
 
-func (w *websocketPeer) Send(msg wamp.Message) error {
-	w.wr <- msg
-	return nil
+// Stats returns a snapshot of the outbound queue's instrumentation
+// counters.
+func (w *websocketPeer) Stats() QueueStats { return w.queue.Stats() }
+
+// CloseTimeoutPeer is implemented by peers that support a graceful, timed
+// close.  Since wamp.Peer only declares Close, callers that need to observe
+// whether the goodbye round-trip completed, or that need to forward the
+// capability from a wrapping peer, type assert for it:
+//
+//	if ctp, ok := peer.(transport.CloseTimeoutPeer); ok {
+//		err := ctp.CloseWithTimeout(5 * time.Second)
+//	}
+type CloseTimeoutPeer interface {
+	CloseWithTimeout(d time.Duration) error
 }
 
-// Close closes the websocket peer.  This closes the local send channel, and
-// sends a close control message to the websocket to tell the other side to
-// close.
+// Close closes the websocket peer, waiting up to ctrlTimeout for the
+// outbound queue to drain and for the goodbye close handshake to complete.
+// It is retained, alongside CloseWithTimeout, for compatibility with
+// existing callers.
 //
 // *** Do not call Send after calling Close. ***
 func (w *websocketPeer) Close() {
+	w.CloseWithTimeout(ctrlTimeout)
+}
+
+// CloseWithTimeout closes the websocket peer, waiting up to d for the
+// outbound queue to drain and for the close control message to be ACKed by
+// the far side, before forcibly closing the underlying connection.  It
+// returns an error if either wait timed out, so the caller can observe
+// whether the goodbye round-trip actually completed.
+//
+// *** Do not call Send after calling CloseWithTimeout. ***
+func (w *websocketPeer) CloseWithTimeout(d time.Duration) error {
+	deadline := time.Now().Add(d)
+
 	// Tell sendHandler to exit, allowing it to finish sending any queued
-	// messages.  Do not close wr channel in case there are incoming messages
-	// during close.
-	w.wr <- nil
-	<-w.writerDone
+	// messages.  Push, rather than close, the queue in case there are
+	// incoming messages during close.  Bound the push itself by d, so a
+	// full bounded queue whose sendHandler is stuck can't make
+	// CloseWithTimeout wait past its deadline before it even starts
+	// waiting on writerDone.
+	pushCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	pushErr := w.queue.PushContext(pushCtx, nil)
+	cancel()
+	if pushErr != nil {
+		close(w.closed)
+		w.conn.Close()
+		return errors.New("timed out waiting for outbound queue to drain")
+	}
+	select {
+	case <-w.writerDone:
+	case <-time.After(time.Until(deadline)):
+		close(w.closed)
+		w.conn.Close()
+		return errors.New("timed out waiting for outbound queue to drain")
+	}
 
 	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure,
 		"goodbye")
@@ -237,16 +473,24 @@ func (w *websocketPeer) Close() {
 
 	// Ignore errors since websocket may have been closed by other side first
 	// in response to a goodbye message.
-	w.conn.WriteControl(websocket.CloseMessage, closeMsg,
-		time.Now().Add(ctrlTimeout))
+	w.conn.WriteControl(websocket.CloseMessage, closeMsg, deadline)
+
+	var err error
+	select {
+	case <-w.recvDone:
+		// Goodbye round-trip completed, or the connection errored out.
+	case <-time.After(time.Until(deadline)):
+		err = errors.New("timed out waiting for goodbye acknowledgement")
+	}
 	w.conn.Close()
+	return err
 }
 
-// sendHandler pulls messages from the write channel, and pushes them to the
-// websocket.
+// sendHandler pulls messages from the outbound queue, and pushes them to
+// the websocket.
 func (w *websocketPeer) sendHandler() {
 	defer close(w.writerDone)
-	for msg := range w.wr {
+	for msg := range w.queue.Out() {
 		if msg == nil {
 			return
 		}
@@ -256,6 +500,10 @@ func (w *websocketPeer) sendHandler() {
 			continue
 		}
 
+		if w.writeWait != 0 {
+			w.conn.SetWriteDeadline(time.Now().Add(w.writeWait))
+		}
+		w.conn.EnableWriteCompression(len(b) >= w.compressionThreshold)
 		if err = w.conn.WriteMessage(w.payloadType, b); err != nil {
 			if !wamp.IsGoodbyeAck(msg) {
 				w.log.Print(err)
@@ -265,6 +513,85 @@ func (w *websocketPeer) sendHandler() {
 	}
 }
 
+// sendHandlerBatched is like sendHandler, but coalesces messages drained
+// from the outbound queue into a single batched-subprotocol frame, flushed
+// after w.batchWindow elapses or w.maxBatchBytes is reached.
+func (w *websocketPeer) sendHandlerBatched() {
+	defer close(w.writerDone)
+
+	var (
+		pending     [][]byte
+		pendingSize int
+	)
+	timer := time.NewTimer(w.batchWindow)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	timerRunning := false
+
+	flush := func() error {
+		if timerRunning {
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timerRunning = false
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		b := encodeBatch(pending, w.payloadType)
+		pending = nil
+		pendingSize = 0
+
+		if w.writeWait != 0 {
+			w.conn.SetWriteDeadline(time.Now().Add(w.writeWait))
+		}
+		w.conn.EnableWriteCompression(len(b) >= w.compressionThreshold)
+		return w.conn.WriteMessage(w.payloadType, b)
+	}
+
+	for {
+		select {
+		case msg, open := <-w.queue.Out():
+			if !open {
+				flush()
+				return
+			}
+			if msg == nil {
+				if err := flush(); err != nil {
+					w.log.Print(err)
+				}
+				return
+			}
+			b, err := w.serializer.Serialize(msg.(wamp.Message))
+			if err != nil {
+				w.log.Print(err)
+				continue
+			}
+			pending = append(pending, b)
+			pendingSize += len(b)
+			if !timerRunning {
+				timer.Reset(w.batchWindow)
+				timerRunning = true
+			}
+			if w.maxBatchBytes > 0 && pendingSize >= w.maxBatchBytes {
+				if err := flush(); err != nil {
+					if !wamp.IsGoodbyeAck(msg) {
+						w.log.Print(err)
+					}
+					return
+				}
+			}
+		case <-timer.C:
+			timerRunning = false
+			if err := flush(); err != nil {
+				w.log.Print(err)
+				return
+			}
+		}
+	}
+}
+
 func (w *websocketPeer) sendHandlerKeepAlive(keepAlive time.Duration) {
 	defer close(w.writerDone)
 
@@ -272,6 +599,9 @@ func (w *websocketPeer) sendHandlerKeepAlive(keepAlive time.Duration) {
 	w.conn.SetPongHandler(func(msg string) error {
 		// Any response resets counter.
 		atomic.StoreInt32(&pendingPongs, 0)
+		if w.readWait != 0 {
+			w.conn.SetReadDeadline(time.Now().Add(w.readWait))
+		}
 		return nil
 	})
 
@@ -282,7 +612,7 @@ func (w *websocketPeer) sendHandlerKeepAlive(keepAlive time.Duration) {
 recvLoop:
 	for {
 		select {
-		case msg, open := <-w.wr:
+		case msg, open := <-w.queue.Out():
 			if msg == nil || !open {
 				return
 			}
@@ -292,6 +622,10 @@ recvLoop:
 				continue recvLoop
 			}
 
+			if w.writeWait != 0 {
+				w.conn.SetWriteDeadline(time.Now().Add(w.writeWait))
+			}
+			w.conn.EnableWriteCompression(len(b) >= w.compressionThreshold)
 			if err = w.conn.WriteMessage(w.payloadType, b); err != nil {
 				if !wamp.IsGoodbyeAck(msg) {
 					w.log.Print(err)
@@ -306,6 +640,9 @@ recvLoop:
 				return
 			}
 			// Send websocket ping.
+			if w.writeWait != 0 {
+				w.conn.SetWriteDeadline(time.Now().Add(w.writeWait))
+			}
 			err := w.conn.WriteMessage(websocket.PingMessage, pingMsg)
 			if err != nil {
 				return
@@ -321,9 +658,16 @@ func (w *websocketPeer) recvHandler() {
 	// When done, close read channel to cause router to remove session if not
 	// already removed.
 	defer close(w.rd)
+	defer close(w.recvDone)
 	defer w.conn.Close()
+	if w.readWait != 0 {
+		w.conn.SetReadDeadline(time.Now().Add(w.readWait))
+	}
 	for {
 		msgType, b, err := w.conn.ReadMessage()
+		if err == nil && w.readWait != 0 {
+			w.conn.SetReadDeadline(time.Now().Add(w.readWait))
+		}
 		if err != nil {
 			select {
 			case <-w.closed:
@@ -331,10 +675,10 @@ func (w *websocketPeer) recvHandler() {
 				// been told to exit.
 			default:
 				// Peer received control message to close.  Cause sendHandler
-				// to exit without closing the write channel (in case writes
+				// to exit without closing the outbound queue (in case writes
 				// still happening) and allow it to finish sending any queued
 				// messages.
-				w.wr <- nil
+				w.queue.Push(nil)
 				<-w.writerDone
 			}
 			// The error is only one of these errors.  It is generally not
@@ -350,29 +694,41 @@ func (w *websocketPeer) recvHandler() {
 			return
 		}
 
-		msg, err := w.serializer.Deserialize(b)
-		if err != nil {
-			// TODO: something more than merely logging?
-			w.log.Println("Cannot deserialize peer message:", err)
-			continue
+		frames := [][]byte{b}
+		if w.batched {
+			var err error
+			frames, err = decodeBatch(b, msgType)
+			if err != nil {
+				w.log.Println("Cannot split batched frame:", err)
+				continue
+			}
 		}
-		// It is OK for the router to block a client since routing should be
-		// very quick compared to the time to transfer a message over
-		// websocket, and a blocked client will not block other clients.
-		//
-		// Need to wake up on w.closed so this goroutine can exit in the case
-		// that messages are not being read from the peer and prevent this
-		// write from completing.
-		select {
-		case w.rd <- msg:
-		case <-w.closed:
-			// If closed, try for one second to send the last message and then
-			// exit recvHandler.
+
+		for _, frame := range frames {
+			msg, err := w.serializer.Deserialize(frame)
+			if err != nil {
+				// TODO: something more than merely logging?
+				w.log.Println("Cannot deserialize peer message:", err)
+				continue
+			}
+			// It is OK for the router to block a client since routing should
+			// be very quick compared to the time to transfer a message over
+			// websocket, and a blocked client will not block other clients.
+			//
+			// Need to wake up on w.closed so this goroutine can exit in the
+			// case that messages are not being read from the peer and
+			// prevent this write from completing.
 			select {
 			case w.rd <- msg:
-			case <-time.After(time.Second):
+			case <-w.closed:
+				// If closed, try for one second to send the last message and
+				// then exit recvHandler.
+				select {
+				case w.rd <- msg:
+				case <-time.After(time.Second):
+				}
+				return
 			}
-			return
 		}
 	}
 }